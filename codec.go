@@ -0,0 +1,101 @@
+package cbornode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// canonicalEncOptions pins down every knob that affects determinism: map
+// keys sorted by the bytewise lexicographic order of their encoded form,
+// shortest-form integers, definite-length maps/arrays only, full-width
+// float64 (DAG-CBOR's canonical form never shortens floats - doing so
+// would produce a different encoding, and so a different CID, than every
+// other DAG-CBOR implementation for the same logical object), and no
+// NaN/Inf floats. Node.Cid() hashes RawData(), so any two encoders that
+// disagree on one of these would silently produce different CIDs for the
+// same logical object.
+func canonicalEncOptions() cbor.EncOptions {
+	return cbor.EncOptions{
+		Sort:          cbor.SortBytewiseLexical,
+		ShortestFloat: cbor.ShortestFloatNone,
+		NaNConvert:    cbor.NaNConvertReject,
+		InfConvert:    cbor.InfConvertReject,
+		BigIntConvert: cbor.BigIntConvertShortest,
+		IndefLength:   cbor.IndefLengthForbidden,
+	}
+}
+
+// EncOptions returns the deterministic CBOR encoding options this package
+// uses when producing DAG-CBOR objects.
+func EncOptions() cbor.EncOptions {
+	return canonicalEncOptions()
+}
+
+// DecOptions returns the permissive CBOR decoding options used by Decode
+// and DecodeInto. Use DecodeStrict, not these options, when the input needs
+// to be validated as canonical before its CID is trusted.
+func DecOptions() cbor.DecOptions {
+	return cbor.DecOptions{
+		DupMapKey:   cbor.DupMapKeyQuiet,
+		IndefLength: cbor.IndefLengthAllowed,
+	}
+}
+
+// strictDecOptions additionally refuses duplicate map keys, since a
+// duplicate key is ambiguous input that a canonical encoder would never
+// produce.
+func strictDecOptions() cbor.DecOptions {
+	return cbor.DecOptions{
+		DupMapKey:   cbor.DupMapKeyEnforcedAPF,
+		IndefLength: cbor.IndefLengthForbidden,
+	}
+}
+
+var (
+	encMode       = mustEncMode(canonicalEncOptions())
+	decMode       = mustDecMode(DecOptions())
+	strictDecMode = mustDecMode(strictDecOptions())
+)
+
+func mustEncMode(opts cbor.EncOptions) cbor.EncMode {
+	mode, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}
+
+func mustDecMode(opts cbor.DecOptions) cbor.DecMode {
+	mode, err := opts.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}
+
+// DecodeStrict decodes b the same way Decode does, but additionally rejects
+// input that is not already canonical: duplicate map keys, indefinite
+// length items, bytes that simply don't re-encode to what this package
+// would have produced for the same object, or content (e.g. a NaN/Inf
+// float) that encMode refuses to re-encode at all. Use this instead of
+// Decode when verifying a block against its CID.
+func DecodeStrict(b []byte) (*Node, error) {
+	out := new(Node)
+	if err := strictDecMode.Unmarshal(b, &out.obj); err != nil {
+		return nil, err
+	}
+
+	raw, err := encodeCanonical(out.obj)
+	if err != nil {
+		return nil, fmt.Errorf("cbor input cannot be re-encoded: %w", err)
+	}
+
+	if !bytes.Equal(raw, b) {
+		return nil, errors.New("cbor input is not canonical")
+	}
+
+	return out, nil
+}
@@ -0,0 +1,323 @@
+package cbornode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	cid "github.com/ipfs/go-cid"
+)
+
+// DAG-JSON-style keys used to represent CBOR constructs that have no direct
+// JSON equivalent. All of them live under the reserved "/" key, the same
+// convention used for links, so a plain JSON consumer can at least tell
+// "this was something special" apart from "this is user data called /".
+const (
+	jsonBytesKey = "bytes"
+	jsonTagKey   = "tag"
+	jsonValueKey = "value"
+)
+
+// CBOR tags 2 and 3 are the RFC 8949 bignum tags: a positive or negative
+// arbitrary-precision integer carried as a big-endian byte string.
+const (
+	tagPositiveBignum = 2
+	tagNegativeBignum = 3
+)
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	out, err := toSaneMap(n.obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reconstructs a CBOR object from the JSON produced by
+// MarshalJSON, including the "/" conventions used for links, byte strings,
+// tags and big integers. It is lossy only where JSON itself is ambiguous:
+// an object key that looks like a decimal integer round-trips as an integer
+// key, even if it started life as the string "123"; bool and float keys
+// come back as strings; and a float with an integral value (2.0 is written
+// as 2) comes back as an integer.
+func (n *Node) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	obj, err := fromSaneValue(raw)
+	if err != nil {
+		return err
+	}
+
+	m, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("expected a JSON object at the top level")
+	}
+
+	n.obj = m
+	return nil
+}
+
+func toSaneMap(n map[interface{}]interface{}) (interface{}, error) {
+	if lnk, ok := n["/"]; ok && len(n) == 1 {
+		lnkb, ok := lnk.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("link value should have been bytes")
+		}
+
+		c, err := cid.Cast(lnkb)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"/": c.String()}, nil
+	}
+
+	out := make(map[string]interface{})
+	for k, v := range n {
+		ks, err := jsonifyMapKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		obj, err := convertToJsonIsh(v)
+		if err != nil {
+			return nil, err
+		}
+
+		out[ks] = obj
+	}
+
+	return out, nil
+}
+
+// jsonifyMapKey stringifies a CBOR map key into the string JSON requires.
+// Integer keys become their decimal form; only keys with no sane textual
+// representation are rejected.
+func jsonifyMapKey(k interface{}) (string, error) {
+	switch k := k.(type) {
+	case string:
+		return k, nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	case bool:
+		return strconv.FormatBool(k), nil
+	case float64:
+		return strconv.FormatFloat(k, 'g', -1, 64), nil
+	default:
+		return "", fmt.Errorf("map key %v (%T) has no JSON representation", k, k)
+	}
+}
+
+func convertToJsonIsh(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		return toSaneMap(v)
+	case []interface{}:
+		var out []interface{}
+		for _, i := range v {
+			obj, err := convertToJsonIsh(i)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, obj)
+		}
+		return out, nil
+	case []byte:
+		return map[string]interface{}{
+			"/": map[string]interface{}{
+				jsonBytesKey: base64.RawURLEncoding.EncodeToString(v),
+			},
+		}, nil
+	case big.Int:
+		return jsonifyBigInt(&v), nil
+	case *big.Int:
+		return jsonifyBigInt(v), nil
+	case cbor.Tag:
+		if v.Number == cidLinkTag {
+			l, err := linkCast(v)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]interface{}{"/": l.Cid.String()}, nil
+		}
+
+		inner, err := convertToJsonIsh(v.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"/": map[string]interface{}{
+				jsonTagKey:   v.Number,
+				jsonValueKey: inner,
+			},
+		}, nil
+	default:
+		return v, nil
+	}
+}
+
+func jsonifyBigInt(i *big.Int) interface{} {
+	return map[string]interface{}{
+		"/": map[string]interface{}{
+			jsonTagKey:   bignumTag(i),
+			jsonValueKey: i.String(),
+		},
+	}
+}
+
+func bignumTag(i *big.Int) int {
+	if i.Sign() < 0 {
+		return tagNegativeBignum
+	}
+	return tagPositiveBignum
+}
+
+// fromSaneValue is the inverse of convertToJsonIsh/toSaneMap: it turns the
+// generic value produced by decoding JSON (with UseNumber set) back into
+// the CBOR-ish shapes Decode would have produced.
+func fromSaneValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return fromSaneMap(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			conv, err := fromSaneValue(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = conv
+		}
+		return out, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	default:
+		return v, nil
+	}
+}
+
+func fromSaneMap(m map[string]interface{}) (interface{}, error) {
+	if special, ok := m["/"]; ok && len(m) == 1 {
+		return fromSaneLink(special)
+	}
+
+	out := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		conv, err := fromSaneValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		out[mapKeyFromString(k)] = conv
+	}
+
+	return out, nil
+}
+
+// mapKeyFromString recovers an integer key if k is exactly its decimal
+// form, otherwise leaves it as a string key.
+func mapKeyFromString(k string) interface{} {
+	if i, err := strconv.ParseInt(k, 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(k, 10, 64); err == nil {
+		return u
+	}
+
+	return k
+}
+
+func fromSaneLink(special interface{}) (interface{}, error) {
+	switch special := special.(type) {
+	case string:
+		c, err := cid.Decode(special)
+		if err != nil {
+			return nil, err
+		}
+
+		return cbor.Tag{
+			Number:  cidLinkTag,
+			Content: append([]byte{multibaseIdentity}, c.Bytes()...),
+		}, nil
+	case map[string]interface{}:
+		if enc, ok := special[jsonBytesKey]; ok {
+			encs, ok := enc.(string)
+			if !ok {
+				return nil, errors.New("bytes value must be a string")
+			}
+
+			return base64.RawURLEncoding.DecodeString(encs)
+		}
+
+		tagv, hasTag := special[jsonTagKey]
+		if !hasTag {
+			return nil, fmt.Errorf("unrecognised \"/\" object: %v", special)
+		}
+
+		tagn, err := tagNumber(tagv)
+		if err != nil {
+			return nil, err
+		}
+
+		if tagn == tagPositiveBignum || tagn == tagNegativeBignum {
+			s, ok := special[jsonValueKey].(string)
+			if !ok {
+				return nil, errors.New("bignum value must be a decimal string")
+			}
+
+			i, ok := new(big.Int).SetString(s, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid bignum value: %q", s)
+			}
+
+			return *i, nil
+		}
+
+		inner, err := fromSaneValue(special[jsonValueKey])
+		if err != nil {
+			return nil, err
+		}
+
+		return cbor.Tag{Number: uint64(tagn), Content: inner}, nil
+	default:
+		return nil, fmt.Errorf("unrecognised \"/\" value: %v", special)
+	}
+}
+
+func tagNumber(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Int64()
+	case float64:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("tag number must be an integer, got %T", v)
+	}
+}
@@ -0,0 +1,83 @@
+package cbornode
+
+import (
+	"encoding/json"
+	"testing"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	cid "github.com/ipfs/go-cid"
+)
+
+const testLinkCidString = "bafkreihwsnuregceqpjctqr7ranmrlv5xa2xq72uezcs7jnb6gh2xfnddy"
+
+func TestMarshalJSONLinkNotDoubleWrapped(t *testing.T) {
+	c, err := cid.Decode(testLinkCidString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := WrapMap(map[interface{}]interface{}{
+		"foo": cbor.Tag{
+			Number:  cidLinkTag,
+			Content: append([]byte{multibaseIdentity}, c.Bytes()...),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := n.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	link, ok := out["foo"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected foo to be a link object, got %#v", out["foo"])
+	}
+
+	if got := link["/"]; got != c.String() {
+		t.Fatalf("expected link to be %q, got %#v (double-wrapped?)", c.String(), got)
+	}
+}
+
+func TestJSONRoundTripLink(t *testing.T) {
+	c, err := cid.Decode(testLinkCidString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := WrapMap(map[interface{}]interface{}{
+		"foo": cbor.Tag{
+			Number:  cidLinkTag,
+			Content: append([]byte{multibaseIdentity}, c.Bytes()...),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := n.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roundTripped Node
+	if err := roundTripped.UnmarshalJSON(b); err != nil {
+		t.Fatalf("UnmarshalJSON failed to parse its own MarshalJSON output: %v", err)
+	}
+
+	lnk, _, err := roundTripped.ResolveLink([]string{"foo"})
+	if err != nil {
+		t.Fatalf("ResolveLink on round-tripped node: %v", err)
+	}
+
+	if !lnk.Cid.Equals(c) {
+		t.Fatalf("expected cid %s, got %s", c, lnk.Cid)
+	}
+}
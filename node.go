@@ -4,34 +4,48 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 
+	cbor "github.com/fxamacker/cbor/v2"
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-node"
 	mh "github.com/multiformats/go-multihash"
-	cbor "github.com/whyrusleeping/cbor/go"
 )
 
 func Decode(b []byte) (*Node, error) {
 	out := new(Node)
-	err := cbor.Loads(b, &out.obj)
+	err := decMode.Unmarshal(b, &out.obj)
 	if err != nil {
 		return nil, err
 	}
 
+	// decMode is permissive about things encMode refuses to ever produce,
+	// such as NaN/Inf floats. Reject those here rather than handing back a
+	// Node whose RawData/Cid/Size/String/Loggable would have no choice but
+	// to panic the first time anything tried to re-encode it.
+	if _, err := encodeCanonical(out.obj); err != nil {
+		return nil, fmt.Errorf("cbornode: decoded object cannot be re-encoded: %w", err)
+	}
+
 	return out, nil
 }
 
 func EncodeObject(obj interface{}) ([]byte, error) {
-	return cbor.Dumps(obj)
+	return encMode.Marshal(obj)
+}
+
+// encodeCanonical is the encMode.Marshal call RawData relies on, factored
+// out so the few places that need to validate re-encodability up front
+// (Decode, WrapMap, DecodeStrict) can do so without risking RawData's panic.
+func encodeCanonical(obj map[interface{}]interface{}) ([]byte, error) {
+	return encMode.Marshal(obj)
 }
 
 // DecodeInto decodes a serialized ipld cbor object into the given object.
 func DecodeInto(b []byte, v interface{}) error {
-	// The cbor library really doesnt make this sort of operation easy on us when we are implementing
-	// the `ToCBOR` method.
+	// Going through the jsonable map is still the simplest way to land
+	// arbitrary CBOR onto an arbitrary Go struct without per-type glue.
 	var m map[interface{}]interface{}
-	err := cbor.Loads(b, &m)
+	err := decMode.Unmarshal(b, &m)
 	if err != nil {
 		return err
 	}
@@ -51,26 +65,78 @@ func DecodeInto(b []byte, v interface{}) error {
 
 var ErrNoSuchLink = errors.New("no such link found")
 
+// cidLinkTag is the DAG-CBOR tag number reserved for CID links (see
+// https://github.com/ipld/specs/blob/master/block-layer/codecs/dag-cbor.md).
+const cidLinkTag = 42
+
 type Node struct {
 	obj map[interface{}]interface{}
 }
 
 func WrapMap(m map[interface{}]interface{}) (*Node, error) {
+	if _, err := encodeCanonical(m); err != nil {
+		return nil, fmt.Errorf("cbornode: object cannot be encoded: %w", err)
+	}
+
 	return &Node{m}, nil
 }
 
 type Link struct {
 	Target *cid.Cid `json:"/" cbor:"/"`
+
+	// Legacy, when set, makes MarshalCBOR write the old `{"/": bytes}`
+	// map form instead of a tag-42 link. It's a field rather than a
+	// package-level switch so the choice travels with the value being
+	// encoded, e.g. while migrating a store that still has readers that
+	// don't understand tag 42 yet, instead of being shared mutable state
+	// that concurrent encodes would race on.
+	Legacy bool `json:"-" cbor:"-"`
 }
 
-func (l *Link) ToCBOR(w io.Writer, enc *cbor.Encoder) error {
-	obj := map[string]interface{}{
-		"/": l.Target.Bytes(),
+// MarshalCBOR implements cbor.Marshaler so a *Link embedded in an object
+// encodes as a DAG-CBOR tag-42 link (or, with Legacy set, the old
+// `{"/": bytes}` map form).
+func (l *Link) MarshalCBOR() ([]byte, error) {
+	if l.Legacy {
+		return encMode.Marshal(map[string]interface{}{
+			"/": l.Target.Bytes(),
+		})
 	}
 
-	return enc.Encode(obj)
+	return encMode.Marshal(cbor.Tag{
+		Number:  cidLinkTag,
+		Content: append([]byte{multibaseIdentity}, l.Target.Bytes()...),
+	})
+}
+
+// UnmarshalCBOR implements cbor.Unmarshaler, accepting both encodings that
+// MarshalCBOR can produce.
+func (l *Link) UnmarshalCBOR(b []byte) error {
+	var raw interface{}
+	if err := decMode.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if m, ok := raw.(map[interface{}]interface{}); ok {
+		raw, ok = m["/"]
+		if !ok {
+			return errors.New("incorrectly formatted link")
+		}
+	}
+
+	lnk, err := linkCast(raw)
+	if err != nil {
+		return err
+	}
+
+	l.Target = lnk.Cid
+	return nil
 }
 
+// multibaseIdentity is the multibase prefix byte (0x00) that DAG-CBOR
+// requires before the raw CID bytes inside a tag-42 link.
+const multibaseIdentity = 0x00
+
 func (n Node) Resolve(path []string) (interface{}, []string, error) {
 	cur := n.obj
 	for i, val := range path {
@@ -79,6 +145,16 @@ func (n Node) Resolve(path []string) (interface{}, []string, error) {
 			return nil, nil, ErrNoSuchLink
 		}
 
+		if tag, ok := next.(cbor.Tag); ok {
+			out, err := linkCast(tag)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			out.Name = val
+			return out, path[i+1:], nil
+		}
+
 		nextmap, ok := next.(map[interface{}]interface{})
 		if !ok {
 			return nil, nil, errors.New("tried to resolve through object that had no links")
@@ -114,18 +190,37 @@ func (n Node) ResolveLink(path []string) (*node.Link, []string, error) {
 	return nil, rest, fmt.Errorf("found non-link at given path")
 }
 
+// linkCast converts either encoding of a link into a node.Link: the legacy
+// `{"/": bytes}` map form (lnk is the raw CID bytes) or a DAG-CBOR tag-42
+// value (lnk is a cbor.Tag wrapping the multibase-prefixed CID bytes).
 func linkCast(lnk interface{}) (*node.Link, error) {
-	lnkb, ok := lnk.([]byte)
-	if !ok {
-		return nil, errors.New("incorrectly formatted link")
-	}
+	switch lnk := lnk.(type) {
+	case cbor.Tag:
+		if lnk.Number != cidLinkTag {
+			return nil, fmt.Errorf("unsupported cbor tag for link: %d", lnk.Number)
+		}
 
-	c, err := cid.Cast(lnkb)
-	if err != nil {
-		return nil, err
-	}
+		lnkb, ok := lnk.Content.([]byte)
+		if !ok || len(lnkb) == 0 || lnkb[0] != multibaseIdentity {
+			return nil, errors.New("incorrectly formatted tag-42 link")
+		}
 
-	return &node.Link{Cid: c}, nil
+		c, err := cid.Cast(lnkb[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		return &node.Link{Cid: c}, nil
+	case []byte:
+		c, err := cid.Cast(lnk)
+		if err != nil {
+			return nil, err
+		}
+
+		return &node.Link{Cid: c}, nil
+	default:
+		return nil, errors.New("incorrectly formatted link")
+	}
 }
 
 func (n Node) Tree() []string {
@@ -176,6 +271,15 @@ func traverse(obj map[interface{}]interface{}, cur string, cb func(string, inter
 			if err := traverse(v, this, cb); err != nil {
 				return err
 			}
+		case cbor.Tag:
+			l, err := linkCast(v)
+			if err != nil {
+				return err
+			}
+
+			if err := cb(this, l); err != nil {
+				return err
+			}
 		default:
 			if err := cb(this, v); err != nil {
 				return err
@@ -187,9 +291,11 @@ func traverse(obj map[interface{}]interface{}, cur string, cb func(string, inter
 }
 
 func (n Node) RawData() []byte {
-	b, err := cbor.Dumps(n.obj)
+	b, err := encodeCanonical(n.obj)
 	if err != nil {
-		// not sure this can ever happen
+		// Decode and WrapMap both already reject anything that fails to
+		// re-encode, so a Node reaching here with unencodable content
+		// would mean one of them has a bug, not that the data is bad.
 		panic(err)
 	}
 
@@ -221,65 +327,4 @@ func (n Node) String() string {
 	return n.Cid().String()
 }
 
-func (n Node) MarshalJSON() ([]byte, error) {
-	out, err := toSaneMap(n.obj)
-	if err != nil {
-		return nil, err
-	}
-
-	return json.Marshal(out)
-}
-
-func toSaneMap(n map[interface{}]interface{}) (interface{}, error) {
-	if lnk, ok := n["/"]; ok && len(n) == 1 {
-		lnkb, ok := lnk.([]byte)
-		if !ok {
-			return nil, fmt.Errorf("link value should have been bytes")
-		}
-
-		c, err := cid.Cast(lnkb)
-		if err != nil {
-			return nil, err
-		}
-
-		return &Link{c}, nil
-	}
-	out := make(map[string]interface{})
-	for k, v := range n {
-		ks, ok := k.(string)
-		if !ok {
-			return nil, fmt.Errorf("map keys must be strings")
-		}
-
-		obj, err := convertToJsonIsh(v)
-		if err != nil {
-			return nil, err
-		}
-
-		out[ks] = obj
-	}
-
-	return out, nil
-}
-
-func convertToJsonIsh(v interface{}) (interface{}, error) {
-	switch v := v.(type) {
-	case map[interface{}]interface{}:
-		return toSaneMap(v)
-	case []interface{}:
-		var out []interface{}
-		for _, i := range v {
-			obj, err := convertToJsonIsh(i)
-			if err != nil {
-				return nil, err
-			}
-
-			out = append(out, obj)
-		}
-		return out, nil
-	default:
-		return v, nil
-	}
-}
-
-var _ node.Node = (*Node)(nil)
\ No newline at end of file
+var _ node.Node = (*Node)(nil)
@@ -0,0 +1,49 @@
+package cbornode
+
+import (
+	"math"
+	"testing"
+
+	cbor "github.com/fxamacker/cbor/v2"
+)
+
+// permissiveMarshal encodes v the way decMode's input might have come from
+// some other encoder that, unlike encMode, doesn't reject NaN/Inf floats.
+func permissiveMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	mode, err := cbor.EncOptions{}.EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := mode.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestDecodeRejectsUnencodableFloat(t *testing.T) {
+	b := permissiveMarshal(t, map[string]interface{}{"x": math.NaN()})
+
+	if _, err := Decode(b); err == nil {
+		t.Fatal("expected Decode to reject a block containing a NaN float")
+	}
+}
+
+func TestWrapMapRejectsUnencodableFloat(t *testing.T) {
+	_, err := WrapMap(map[interface{}]interface{}{"x": math.Inf(1)})
+	if err == nil {
+		t.Fatal("expected WrapMap to reject a map containing +Inf")
+	}
+}
+
+func TestDecodeStrictReturnsErrorNotPanicOnUnencodableFloat(t *testing.T) {
+	b := permissiveMarshal(t, map[string]interface{}{"x": math.NaN()})
+
+	if _, err := DecodeStrict(b); err == nil {
+		t.Fatal("expected DecodeStrict to return an error for a block containing a NaN float")
+	}
+}
@@ -0,0 +1,492 @@
+package cbornode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	cbor "github.com/fxamacker/cbor/v2"
+	node "github.com/ipfs/go-ipld-node"
+)
+
+// Kind classifies a value delivered to a Scan visitor so callers don't have
+// to type-switch on val themselves.
+type Kind int
+
+const (
+	KindMap Kind = iota
+	KindArray
+	KindString
+	KindBytes
+	KindInt
+	KindUint
+	KindBool
+	KindNull
+	KindFloat
+	KindLink
+	KindTag
+	KindOther
+)
+
+// DecodeReader is Decode for callers that already have an io.Reader (e.g. a
+// blockstore Get). It still materializes the whole object; use Scan
+// directly to avoid that.
+func DecodeReader(r io.Reader) (*Node, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(b)
+}
+
+// EncodeTo is EncodeObject for callers writing straight to a stream.
+func EncodeTo(w io.Writer, obj interface{}) error {
+	b, err := EncodeObject(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Scan walks a CBOR-encoded object from r without ever materializing the
+// whole thing as a map[interface{}]interface{}: it descends through nested
+// maps key by key, and for anything else (a scalar, array, tag, or link) it
+// decodes just that one value and hands it to visitor. ScanTree and
+// ScanLinks are built on it, so memory is bounded by the largest single
+// non-map value rather than by the entire block; ResolveFromReader uses the
+// same reader and stops as soon as path is resolved. Node's own Links, Tree
+// and Resolve still walk the fully decoded map.
+func Scan(r io.Reader, visitor func(path []string, kind Kind, val interface{}) error) error {
+	cr := &cborReader{br: bufio.NewReader(r)}
+	return cr.scanValue(nil, visitor)
+}
+
+// ScanTree is Node.Tree built on top of Scan.
+func ScanTree(r io.Reader) ([]string, error) {
+	var out []string
+	err := Scan(r, func(path []string, kind Kind, val interface{}) error {
+		out = append(out, "/"+joinPath(path))
+		return nil
+	})
+	return out, err
+}
+
+// ScanLinks is Node.Links built on top of Scan.
+func ScanLinks(r io.Reader) ([]*node.Link, error) {
+	var out []*node.Link
+	err := Scan(r, func(path []string, kind Kind, val interface{}) error {
+		if kind == KindLink {
+			out = append(out, val.(*node.Link))
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ResolveFromReader is Node.Resolve built directly on the CBOR stream: it
+// only decodes the branch leading to path, skipping the encoded bytes of
+// every sibling it passes over instead of decoding them.
+func ResolveFromReader(r io.Reader, path []string) (interface{}, []string, error) {
+	cr := &cborReader{br: bufio.NewReader(r)}
+	val, rest, err := cr.resolveValue(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// resolveValue's recursion doesn't know, at the frame where a link is
+	// actually built, how many outer keys led to it - but the segment
+	// count always does: it's whatever of path wasn't left over in rest.
+	if l, ok := val.(*node.Link); ok && len(rest) < len(path) {
+		l.Name = path[len(path)-len(rest)-1]
+	}
+
+	return val, rest, nil
+}
+
+func joinPath(path []string) string {
+	out := ""
+	for i, p := range path {
+		if i > 0 {
+			out += "/"
+		}
+		out += p
+	}
+	return out
+}
+
+// cborReader is a minimal definite-length-only CBOR cursor. DAG-CBOR (and
+// everything this package encodes) never produces indefinite-length items,
+// so that's the only form Scan/Resolve need to understand.
+type cborReader struct {
+	br *bufio.Reader
+}
+
+func (r *cborReader) readHeader(w io.Writer) (major byte, info byte, arg uint64, err error) {
+	b, err := r.br.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if _, err = w.Write([]byte{b}); err != nil {
+		return 0, 0, 0, err
+	}
+
+	major = b >> 5
+	info = b & 0x1f
+
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		var buf [1]byte
+		if _, err = io.ReadFull(r.br, buf[:]); err != nil {
+			return
+		}
+		if _, err = w.Write(buf[:]); err != nil {
+			return
+		}
+		arg = uint64(buf[0])
+	case info == 25:
+		var buf [2]byte
+		if _, err = io.ReadFull(r.br, buf[:]); err != nil {
+			return
+		}
+		if _, err = w.Write(buf[:]); err != nil {
+			return
+		}
+		arg = uint64(binary.BigEndian.Uint16(buf[:]))
+	case info == 26:
+		var buf [4]byte
+		if _, err = io.ReadFull(r.br, buf[:]); err != nil {
+			return
+		}
+		if _, err = w.Write(buf[:]); err != nil {
+			return
+		}
+		arg = uint64(binary.BigEndian.Uint32(buf[:]))
+	case info == 27:
+		var buf [8]byte
+		if _, err = io.ReadFull(r.br, buf[:]); err != nil {
+			return
+		}
+		if _, err = w.Write(buf[:]); err != nil {
+			return
+		}
+		arg = binary.BigEndian.Uint64(buf[:])
+	default:
+		return 0, 0, 0, fmt.Errorf("indefinite-length cbor items are not supported (additional info %d)", info)
+	}
+
+	return major, info, arg, nil
+}
+
+// skipValue reads one complete CBOR item (recursing through arrays, maps
+// and tags) and writes every byte it consumes to w.
+func (r *cborReader) skipValue(w io.Writer) error {
+	major, _, arg, err := r.readHeader(w)
+	if err != nil {
+		return err
+	}
+
+	switch major {
+	case 0, 1: // uint / negint: value is the header argument, no more bytes
+		return nil
+	case 2, 3: // byte string / text string: arg bytes of content follow
+		_, err := io.CopyN(w, r.br, int64(arg))
+		return err
+	case 4: // array: arg elements follow
+		for i := uint64(0); i < arg; i++ {
+			if err := r.skipValue(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 5: // map: arg key/value pairs follow
+		for i := uint64(0); i < 2*arg; i++ {
+			if err := r.skipValue(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 6: // tag: one wrapped item follows
+		return r.skipValue(w)
+	case 7: // simple value / float: no content beyond the header
+		return nil
+	default:
+		return fmt.Errorf("unsupported cbor major type %d", major)
+	}
+}
+
+// decodeValue captures the bytes of the next single item and fully decodes
+// it, for use once Scan/Resolve have found the value they actually need.
+func (r *cborReader) decodeValue() (interface{}, error) {
+	var buf bytes.Buffer
+	if err := r.skipValue(&buf); err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := decMode.Unmarshal(buf.Bytes(), &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// readMapKey consumes one map key and renders it as a path segment: text
+// strings pass through unchanged, integers become their decimal form (as
+// jsonifyMapKey does for MarshalJSON), and any other key type is an error.
+func (r *cborReader) readMapKey() (string, error) {
+	peek, err := r.br.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	switch peek[0] >> 5 {
+	case 3: // text string
+		_, _, arg, err := r.readHeader(io.Discard)
+		if err != nil {
+			return "", err
+		}
+		buf := make([]byte, arg)
+		if _, err := io.ReadFull(r.br, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	case 0: // uint
+		_, _, arg, err := r.readHeader(io.Discard)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(arg, 10), nil
+	case 1: // negint: encoded value is -1-arg
+		_, _, arg, err := r.readHeader(io.Discard)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(-1-int64(arg), 10), nil
+	default:
+		return "", errors.New("map key was not a string")
+	}
+}
+
+// legacyLinkValue consumes the value that follows a map's lone "/" key
+// (the map header and that key have already been read) and returns it cast
+// as a node.Link by linkCast. It returns an error both on a stream error
+// and when the value is not something linkCast accepts.
+func (r *cborReader) legacyLinkValue() (*node.Link, error) {
+	raw, err := r.decodeValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return linkCast(raw)
+}
+
+func appendKey(path []string, key string) []string {
+	sub := make([]string, len(path)+1)
+	copy(sub, path)
+	sub[len(path)] = key
+	return sub
+}
+
+func (r *cborReader) scanValue(path []string, cb func([]string, Kind, interface{}) error) error {
+	peek, err := r.br.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	if peek[0]>>5 == 5 {
+		_, _, arg, err := r.readHeader(io.Discard)
+		if err != nil {
+			return err
+		}
+
+		// A map whose only key is "/" is chunk0-1's legacy link form, the
+		// same special case traverse() checks before recursing.
+		if arg == 1 {
+			key, err := r.readMapKey()
+			if err != nil {
+				return err
+			}
+
+			if key == "/" {
+				l, err := r.legacyLinkValue()
+				if err != nil {
+					return err
+				}
+
+				return cb(path, KindLink, l)
+			}
+
+			return r.scanValue(appendKey(path, key), cb)
+		}
+
+		for i := uint64(0); i < arg; i++ {
+			key, err := r.readMapKey()
+			if err != nil {
+				return err
+			}
+
+			if err := r.scanValue(appendKey(path, key), cb); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	v, err := r.decodeValue()
+	if err != nil {
+		return err
+	}
+
+	kind, val := classify(v)
+	return cb(path, kind, val)
+}
+
+// resolveValue implements the same link-only resolution semantics as
+// Node.Resolve: it only ever succeeds by finding a link at or before the
+// end of path, erring if path runs into a bare scalar or runs out entirely
+// without hitting one. A map whose only key is "/" is treated as a legacy
+// link regardless of how much of path remains, mirroring traverse()/
+// Node.Resolve's handling of the pre-tag-42 encoding.
+func (r *cborReader) resolveValue(path []string) (interface{}, []string, error) {
+	peek, err := r.br.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if peek[0]>>5 != 5 {
+		if len(path) == 0 {
+			return nil, nil, errors.New("could not resolve through object")
+		}
+		return nil, nil, errors.New("tried to resolve through object that had no links")
+	}
+
+	_, _, arg, err := r.readHeader(io.Discard)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if arg == 1 {
+		key, err := r.readMapKey()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if key == "/" {
+			l, err := r.legacyLinkValue()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return l, path, nil
+		}
+
+		if len(path) == 0 || key != path[0] {
+			return nil, nil, ErrNoSuchLink
+		}
+
+		return r.resolveMatchedKey(key, path)
+	}
+
+	if len(path) == 0 {
+		return nil, nil, errors.New("could not resolve through object")
+	}
+
+	for i := uint64(0); i < arg; i++ {
+		key, err := r.readMapKey()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if key != path[0] {
+			if err := r.skipValue(io.Discard); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		return r.resolveMatchedKey(key, path)
+	}
+
+	return nil, nil, ErrNoSuchLink
+}
+
+// resolveMatchedKey is called once a map key has been found to equal
+// path[0]; it inspects that key's value to decide whether resolution ends
+// here (a tag-42 link), continues (a nested map, possibly itself a legacy
+// link - resolveValue checks), or fails (a bare scalar).
+func (r *cborReader) resolveMatchedKey(key string, path []string) (interface{}, []string, error) {
+	vpeek, err := r.br.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch vpeek[0] >> 5 {
+	case 6: // tag: only a tag-42 link is a valid target here
+		v, err := r.decodeValue()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tag, ok := v.(cbor.Tag)
+		if !ok || tag.Number != cidLinkTag {
+			return nil, nil, errors.New("tried to resolve through object that had no links")
+		}
+
+		l, err := linkCast(tag)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		l.Name = key
+		return l, path[1:], nil
+	case 5: // nested map: keep descending (resolveValue itself checks for
+		// the legacy single-"/"-key link form)
+		return r.resolveValue(path[1:])
+	default:
+		return nil, nil, errors.New("tried to resolve through object that had no links")
+	}
+}
+
+func classify(v interface{}) (Kind, interface{}) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		return KindMap, v
+	case []interface{}:
+		return KindArray, v
+	case string:
+		return KindString, v
+	case []byte:
+		return KindBytes, v
+	case int64:
+		return KindInt, v
+	case uint64:
+		return KindUint, v
+	case bool:
+		return KindBool, v
+	case nil:
+		return KindNull, v
+	case float32:
+		return KindFloat, v
+	case float64:
+		return KindFloat, v
+	case cbor.Tag:
+		if v.Number == cidLinkTag {
+			if l, err := linkCast(v); err == nil {
+				return KindLink, l
+			}
+		}
+		return KindTag, v
+	default:
+		return KindOther, v
+	}
+}
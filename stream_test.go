@@ -0,0 +1,85 @@
+package cbornode
+
+import (
+	"bytes"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-node"
+)
+
+// legacyLinkBlock encodes {"foo": {"/": <raw cid bytes>}}, the pre-tag-42
+// link form chunk0-1 kept readable via linkCast/traverse.
+func legacyLinkBlock(t *testing.T, c *cid.Cid) []byte {
+	t.Helper()
+
+	b, err := EncodeObject(map[interface{}]interface{}{
+		"foo": map[interface{}]interface{}{
+			"/": c.Bytes(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestResolveFromReaderLegacyLink(t *testing.T) {
+	c, err := cid.Decode(testLinkCidString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := legacyLinkBlock(t, c)
+
+	val, _, err := ResolveFromReader(bytes.NewReader(b), []string{"foo"})
+	if err != nil {
+		t.Fatalf("ResolveFromReader on legacy link: %v", err)
+	}
+
+	lnk, ok := val.(*node.Link)
+	if !ok {
+		t.Fatalf("expected a *node.Link, got %T", val)
+	}
+	if !lnk.Cid.Equals(c) {
+		t.Fatalf("expected cid %s, got %s", c, lnk.Cid)
+	}
+}
+
+func TestScanRecognizesLegacyLink(t *testing.T) {
+	c, err := cid.Decode(testLinkCidString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := legacyLinkBlock(t, c)
+
+	var gotKind Kind
+	var gotCid *cid.Cid
+	found := false
+
+	err = Scan(bytes.NewReader(b), func(path []string, kind Kind, val interface{}) error {
+		if len(path) == 1 && path[0] == "foo" {
+			found = true
+			gotKind = kind
+			if l, ok := val.(*node.Link); ok {
+				gotCid = l.Cid
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found {
+		t.Fatal("did not visit foo")
+	}
+	if gotKind != KindLink {
+		t.Fatalf("expected legacy link to be classified as KindLink, got %v", gotKind)
+	}
+	if gotCid == nil || !gotCid.Equals(c) {
+		t.Fatalf("expected cid %s, got %v", c, gotCid)
+	}
+}
@@ -0,0 +1,202 @@
+package cbornode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// structTag is the struct tag key Marshal/Unmarshal bind fields with:
+// `cborgen:"name,omitempty"`. It's deliberately not "cbor" so a type can
+// carry both this package's tags and a different codec's without clashing.
+const structTag = "cborgen"
+
+// Marshal encodes v, which must be a struct or a pointer to one, straight
+// to DAG-CBOR bytes: a *cid.Cid field becomes a tag-42 link and a []byte
+// field becomes a CBOR byte string. This skips the CBOR -> map -> JSON ->
+// struct round trip DecodeInto pays for getting typed data out of a block.
+func Marshal(v interface{}) ([]byte, error) {
+	m, err := structToMap(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeObject(m)
+}
+
+// Unmarshal decodes b into v, a pointer to a struct whose fields are tagged
+// the way Marshal expects.
+func Unmarshal(b []byte, v interface{}) error {
+	var m map[interface{}]interface{}
+	if err := decMode.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cbornode: Unmarshal target must be a non-nil pointer to struct")
+	}
+
+	return mapToStruct(m, rv.Elem())
+}
+
+type fieldInfo struct {
+	index     int
+	name      string
+	omitempty bool
+}
+
+func structFields(t reflect.Type) ([]fieldInfo, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cbornode: %s is not a struct", t)
+	}
+
+	var out []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported field
+			continue
+		}
+
+		name := f.Name
+		omitempty := false
+
+		if tag, ok := f.Tag.Lookup(structTag); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, p := range parts[1:] {
+				if p == "omitempty" {
+					omitempty = true
+				}
+			}
+		}
+
+		out = append(out, fieldInfo{index: i, name: name, omitempty: omitempty})
+	}
+
+	return out, nil
+}
+
+func structToMap(v reflect.Value) (map[string]interface{}, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("cbornode: cannot marshal nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		fv := v.Field(f.index)
+
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+
+		enc, err := encodeField(fv)
+		if err != nil {
+			return nil, fmt.Errorf("cbornode: field %q: %w", f.name, err)
+		}
+
+		out[f.name] = enc
+	}
+
+	return out, nil
+}
+
+func encodeField(fv reflect.Value) (interface{}, error) {
+	switch v := fv.Interface().(type) {
+	case *cid.Cid:
+		if v == nil {
+			return nil, nil
+		}
+		return &Link{Target: v}, nil
+	case []byte:
+		return v, nil
+	}
+
+	if fv.Kind() == reflect.Struct {
+		return structToMap(fv)
+	}
+
+	return fv.Interface(), nil
+}
+
+func mapToStruct(m map[interface{}]interface{}, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		raw, ok := m[f.name]
+		if !ok {
+			continue
+		}
+
+		if err := decodeField(raw, v.Field(f.index)); err != nil {
+			return fmt.Errorf("cbornode: field %q: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+func decodeField(raw interface{}, fv reflect.Value) error {
+	if raw == nil {
+		// encodeField marshals a nil *cid.Cid/[]byte as CBOR null; leave fv
+		// at its zero value rather than handing nil to linkCast or a bare
+		// type assertion, both of which expect an actual value.
+		return nil
+	}
+
+	switch fv.Interface().(type) {
+	case *cid.Cid:
+		lnk, err := linkCast(raw)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(lnk.Cid))
+		return nil
+	case []byte:
+		b, ok := raw.([]byte)
+		if !ok {
+			return fmt.Errorf("expected cbor byte string, got %T", raw)
+		}
+		fv.SetBytes(b)
+		return nil
+	}
+
+	if fv.Kind() == reflect.Struct {
+		sub, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("expected cbor map, got %T", raw)
+		}
+		return mapToStruct(sub, fv)
+	}
+
+	rv := reflect.ValueOf(raw)
+	if !rv.IsValid() {
+		return nil
+	}
+	if !rv.Type().AssignableTo(fv.Type()) {
+		if !rv.Type().ConvertibleTo(fv.Type()) {
+			return fmt.Errorf("cannot assign %s to %s", rv.Type(), fv.Type())
+		}
+		rv = rv.Convert(fv.Type())
+	}
+	fv.Set(rv)
+	return nil
+}
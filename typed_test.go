@@ -0,0 +1,63 @@
+package cbornode
+
+import (
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+type optionalFields struct {
+	Link  *cid.Cid `cborgen:"link"`
+	Bytes []byte   `cborgen:"bytes"`
+	Name  string   `cborgen:"name"`
+}
+
+func TestMarshalUnmarshalRoundTripsUnsetOptionalFields(t *testing.T) {
+	in := optionalFields{Name: "foo"}
+
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out optionalFields
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal failed on unset *cid.Cid/[]byte fields: %v", err)
+	}
+
+	if out.Link != nil {
+		t.Fatalf("expected Link to stay nil, got %v", out.Link)
+	}
+	if out.Bytes != nil {
+		t.Fatalf("expected Bytes to stay nil, got %v", out.Bytes)
+	}
+	if out.Name != "foo" {
+		t.Fatalf("expected Name %q, got %q", "foo", out.Name)
+	}
+}
+
+func TestMarshalUnmarshalRoundTripsSetLink(t *testing.T) {
+	c, err := cid.Decode(testLinkCidString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := optionalFields{Link: c, Bytes: []byte("hi")}
+
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out optionalFields
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Link == nil || !out.Link.Equals(c) {
+		t.Fatalf("expected Link %s, got %v", c, out.Link)
+	}
+	if string(out.Bytes) != "hi" {
+		t.Fatalf("expected Bytes %q, got %q", "hi", out.Bytes)
+	}
+}